@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
-	"net/http"
 	"strings"
 	"time"
 
@@ -16,6 +15,10 @@ import (
 	"github.com/usememos/memos/store"
 )
 
+// externalIdentityProviderSettingKey is the WorkspaceSetting key under which
+// the list of configured external identity Connectors is stored as JSON.
+const externalIdentityProviderSettingKey = "external_identity_providers"
+
 // Authenticator provides shared authentication and authorization logic.
 // Used by gRPC interceptor, Connect interceptor, and file server to ensure
 // consistent authentication behavior across all API endpoints.
@@ -26,22 +29,180 @@ import (
 //
 // This struct is safe for concurrent use.
 type Authenticator struct {
-	store  *store.Store
-	secret string
+	store       *store.Store
+	secret      string
+	connectors  *connectorRegistry
+	signingKeys *SigningKeySet
+	issuer      string
+	rateLimiter *clientRateLimiter
+	cache       *AuthCache
+
+	lastSeenWrites      *lastSeenCoalescer
+	geoLookup           GeoLookupFunc
+	suspiciousLoginHook SuspiciousLoginHook
+}
+
+// SetGeoLookup configures the IP-to-ASN/country resolver used to detect
+// suspicious logins. Passing nil (the default) disables the check.
+func (a *Authenticator) SetGeoLookup(lookup GeoLookupFunc) {
+	a.geoLookup = lookup
+}
+
+// SetSuspiciousLoginHook registers the callback invoked when a refresh token
+// authentication's or rotation's client IP looks like it moved ASN/country
+// since the session's last known IP. Passing nil (the default) disables the
+// check.
+func (a *Authenticator) SetSuspiciousLoginHook(hook SuspiciousLoginHook) {
+	a.suspiciousLoginHook = hook
 }
 
-// NewAuthenticator creates a new Authenticator instance.
+// NewAuthenticator creates a new Authenticator instance, loads any configured
+// external identity provider connectors from workspace settings, and loads
+// the RSA signing key set used to sign access tokens asymmetrically,
+// generating and persisting one if none is stored yet.
 func NewAuthenticator(store *store.Store, secret string) *Authenticator {
-	return &Authenticator{
-		store:  store,
-		secret: secret,
+	// Loaded from (or seeded into) store.WorkspaceSetting rather than always
+	// generated fresh, so every replica behind a load balancer signs and
+	// verifies access tokens with the same RSA keypair(s).
+	signingKeys, err := loadOrCreateSigningKeySet(context.Background(), store)
+	if err != nil {
+		// This fails when the stored key set can't be read or parsed, when a
+		// new one can't be persisted, or when RSA key generation fails on an
+		// exhausted entropy source; without a key set no access token can be
+		// signed or verified, so callers can't meaningfully handle it
+		// per-request.
+		panic(errors.Wrap(err, "failed to load or generate signing key set"))
+	}
+
+	a := &Authenticator{
+		store:          store,
+		secret:         secret,
+		connectors:     newConnectorRegistry(),
+		signingKeys:    signingKeys,
+		issuer:         defaultIssuer,
+		rateLimiter:    newClientRateLimiter(),
+		cache:          NewAuthCache(defaultAuthCacheSize, []byte(secret)),
+		lastSeenWrites: newLastSeenCoalescer(),
+	}
+	if err := a.ReloadConnectors(context.Background()); err != nil {
+		slog.Warn("failed to load external identity provider connectors", "error", err)
+	}
+	if raw, err := store.GetWorkspaceSetting(context.Background(), &store.FindWorkspaceSetting{Name: instanceURLSettingKey}); err == nil && raw != nil && raw.Value != "" {
+		a.issuer = raw.Value
+	}
+	a.subscribeToUserChanges()
+	return a
+}
+
+// defaultIssuer is used as the OIDC `iss` claim when the workspace has not
+// configured a public instance URL.
+const defaultIssuer = "memos"
+
+// instanceURLSettingKey is the WorkspaceSetting holding the workspace's
+// public-facing URL, reused as the OIDC issuer identifier.
+const instanceURLSettingKey = "instance_url"
+
+// legacyHS256SettingKey is the WorkspaceSetting key controlling how long
+// HS256-signed access tokens (issued before the switch to asymmetric
+// signing) are still accepted, as an RFC 3339 timestamp.
+const legacyHS256SettingKey = "jwt_legacy_hs256_migration_until"
+
+// legacyHS256Allowed reports whether HS256 access tokens should still be
+// accepted, per the workspace's migration window setting. Defaults to false
+// (asymmetric-only) when the setting is absent.
+func (a *Authenticator) legacyHS256Allowed(ctx context.Context) bool {
+	raw, err := a.store.GetWorkspaceSetting(ctx, &store.FindWorkspaceSetting{Name: legacyHS256SettingKey})
+	if err != nil || raw == nil || raw.Value == "" {
+		return false
+	}
+	until, err := time.Parse(time.RFC3339, raw.Value)
+	if err != nil {
+		return false
+	}
+	return time.Now().Before(until)
+}
+
+// ReloadConnectors re-reads the external identity provider configuration from
+// store.WorkspaceSetting and rebuilds the connector set. Safe to call at any
+// time, including from a workspace setting change watcher, since connector
+// updates take effect for the next request without restarting the process.
+func (a *Authenticator) ReloadConnectors(ctx context.Context) error {
+	raw, err := a.store.GetWorkspaceSetting(ctx, &store.FindWorkspaceSetting{Name: externalIdentityProviderSettingKey})
+	if err != nil {
+		return errors.Wrap(err, "failed to load external identity provider setting")
+	}
+	if raw == nil {
+		// No deployment has ever configured this setting yet, which is true for
+		// every pre-existing install upgrading from the hard-coded Linkin
+		// integration. Seed a default linkin_compat connector rather than
+		// silently going dark on auth until an admin hand-writes a config.
+		configs := []ConnectorConfig{{ID: "linkin", Type: ConnectorTypeLinkinCompat}}
+		if err := a.persistConnectorConfigs(ctx, configs); err != nil {
+			slog.Warn("failed to seed default linkin_compat connector", "error", err)
+		}
+		a.connectors.set([]Connector{newLinkinCompatConnector(configs[0])})
+		return nil
+	}
+
+	var configs []ConnectorConfig
+	if err := json.Unmarshal([]byte(raw.Value), &configs); err != nil {
+		return errors.Wrap(err, "failed to parse external identity provider setting")
+	}
+
+	connectors := make([]Connector, 0, len(configs))
+	for _, cfg := range configs {
+		connector, err := buildConnector(cfg, a.store)
+		if err != nil {
+			slog.Warn("skipping invalid external identity provider connector", "id", cfg.ID, "error", err)
+			continue
+		}
+		connectors = append(connectors, connector)
+	}
+	a.connectors.set(connectors)
+	return nil
+}
+
+// persistConnectorConfigs writes configs back to the external identity
+// provider WorkspaceSetting, so the seeded default survives restarts and
+// shows up for admins editing the setting going forward.
+func (a *Authenticator) persistConnectorConfigs(ctx context.Context, configs []ConnectorConfig) error {
+	raw, err := json.Marshal(configs)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal external identity provider configs")
+	}
+	_, err = a.store.UpsertWorkspaceSetting(ctx, &store.WorkspaceSetting{
+		Name:  externalIdentityProviderSettingKey,
+		Value: string(raw),
+	})
+	return errors.Wrap(err, "failed to persist external identity provider setting")
+}
+
+// buildConnector constructs the right Connector implementation for a given
+// ConnectorConfig's type.
+func buildConnector(cfg ConnectorConfig, s *store.Store) (Connector, error) {
+	switch cfg.Type {
+	case ConnectorTypeOIDC:
+		if cfg.Issuer == "" {
+			return nil, errors.New("oidc connector requires an issuer")
+		}
+		return NewOIDCConnector(cfg, s), nil
+	case ConnectorTypeOAuth2:
+		return NewOAuth2Connector(cfg), nil
+	case ConnectorTypeBearerIntrospect:
+		return NewBearerIntrospectionConnector(cfg), nil
+	case ConnectorTypeLinkinCompat:
+		return newLinkinCompatConnector(cfg), nil
+	default:
+		return nil, errors.Errorf("unknown connector type %q", cfg.Type)
 	}
 }
 
 // AuthenticateByAccessTokenV2 validates a short-lived access token.
-// Returns claims without database query (stateless validation).
-func (a *Authenticator) AuthenticateByAccessTokenV2(accessToken string) (*UserClaims, error) {
-	claims, err := ParseAccessTokenV2(accessToken, []byte(a.secret))
+// Returns claims without database query (stateless validation). Tokens are
+// verified against the current/previous RSA signing keys; HS256 tokens are
+// only accepted while the workspace's legacy migration window is open.
+func (a *Authenticator) AuthenticateByAccessTokenV2(ctx context.Context, accessToken string) (*UserClaims, error) {
+	claims, err := a.parseAccessTokenV2WithKeys(ctx, accessToken)
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid access token")
 	}
@@ -59,8 +220,10 @@ func (a *Authenticator) AuthenticateByAccessTokenV2(accessToken string) (*UserCl
 	}, nil
 }
 
-// AuthenticateByRefreshToken validates a refresh token against the database.
-func (a *Authenticator) AuthenticateByRefreshToken(ctx context.Context, refreshToken string) (*store.User, string, error) {
+// AuthenticateByRefreshToken validates a refresh token against the database
+// and records the request's device metadata against the session, coalesced
+// to at most one write per session per lastSeenWriteCoalesceWindow.
+func (a *Authenticator) AuthenticateByRefreshToken(ctx context.Context, refreshToken string, meta SessionRequestMetadata) (*store.User, string, error) {
 	claims, err := ParseRefreshToken(refreshToken, []byte(a.secret))
 	if err != nil {
 		return nil, "", errors.Wrap(err, "invalid refresh token")
@@ -80,6 +243,16 @@ func (a *Authenticator) AuthenticateByRefreshToken(ctx context.Context, refreshT
 		return nil, "", errors.New("refresh token revoked")
 	}
 
+	// A rotated token being presented again means the rotation chain has
+	// likely been stolen; burn the whole family rather than trust it.
+	if token.RotatedAt != nil {
+		if revokeErr := a.store.RevokeUserRefreshTokenFamily(ctx, userID, token.FamilyID); revokeErr != nil {
+			slog.Error("failed to revoke refresh token family after reuse detection", "error", revokeErr, "userID", userID, "familyID", token.FamilyID)
+		}
+		a.cache.invalidateUser(userID)
+		return nil, "", errors.New("refresh token reuse detected, family revoked")
+	}
+
 	// Check token not expired
 	if token.ExpiresAt != nil && token.ExpiresAt.AsTime().Before(time.Now()) {
 		return nil, "", errors.New("refresh token expired")
@@ -97,6 +270,8 @@ func (a *Authenticator) AuthenticateByRefreshToken(ctx context.Context, refreshT
 		return nil, "", errors.New("user is archived")
 	}
 
+	a.recordSessionActivity(ctx, userID, claims.TokenID, token.LastSeenIP, meta)
+
 	return user, claims.TokenID, nil
 }
 
@@ -133,14 +308,14 @@ type AuthResult struct {
 }
 
 // Authenticate tries to authenticate using the provided credentials.
-// Priority: 1. Access Token V2, 2. PAT
+// Priority: 1. Access Token V2, 2. PAT, 3. external identity provider connectors.
 // Returns nil if no valid credentials are provided.
 func (a *Authenticator) Authenticate(ctx context.Context, authHeader string) *AuthResult {
 	token := ExtractBearerToken(authHeader)
 
 	// Try Access Token V2 (stateless)
 	if token != "" && !strings.HasPrefix(token, PersonalAccessTokenPrefix) {
-		claims, err := a.AuthenticateByAccessTokenV2(token)
+		claims, err := a.AuthenticateByAccessTokenV2(ctx, token)
 		if err == nil && claims != nil {
 			return &AuthResult{
 				Claims:      claims,
@@ -149,10 +324,15 @@ func (a *Authenticator) Authenticate(ctx context.Context, authHeader string) *Au
 		}
 	}
 
-	// Try PAT
+	// Try PAT, consulting the cache first to avoid a store round trip.
 	if token != "" && strings.HasPrefix(token, PersonalAccessTokenPrefix) {
+		if user, ok := a.cache.getUser(token); ok {
+			return &AuthResult{User: user, AccessToken: token}
+		}
+
 		user, pat, err := a.AuthenticateByPAT(ctx, token)
 		if err == nil && user != nil {
+			a.cache.putUser(token, user, defaultPATCacheTTL, patExpiry(pat))
 			// Update last used (fire-and-forget with logging)
 			go func() {
 				if err := a.store.UpdatePATLastUsed(context.Background(), user.ID, pat.TokenId, timestamppb.Now()); err != nil {
@@ -163,10 +343,17 @@ func (a *Authenticator) Authenticate(ctx context.Context, authHeader string) *Au
 		}
 	}
 
-	// Try Linkin Token (Remote validation)
+	// Try each configured external identity provider connector, in the
+	// order they were configured, again consulting the cache first since
+	// these calls cross the network to the identity provider.
 	if token != "" && !strings.HasPrefix(token, PersonalAccessTokenPrefix) {
-		user, err := a.AuthenticateByLinkinToken(ctx, authHeader)
+		if user, ok := a.cache.getUser(token); ok {
+			return &AuthResult{User: user, AccessToken: token}
+		}
+
+		user, expiresAt, err := a.AuthenticateByExternalConnector(ctx, authHeader)
 		if err == nil && user != nil {
+			a.cache.putUser(token, user, defaultExternalTokenCacheTTL, expiresAt)
 			return &AuthResult{
 				User:        user,
 				AccessToken: token,
@@ -177,68 +364,36 @@ func (a *Authenticator) Authenticate(ctx context.Context, authHeader string) *Au
 	return nil
 }
 
-// AuthenticateByLinkinToken validates a token against Linkin server.
-func (a *Authenticator) AuthenticateByLinkinToken(ctx context.Context, authHeader string) (*store.User, error) {
-	if authHeader == "" {
-		return nil, errors.New("empty auth header")
-	}
-
-	// Call Linkin API to validate token
-	// Assuming Linkin server is running on localhost:8888
-	req, err := http.NewRequestWithContext(ctx, "GET", "http://linkin.love/api/user/v1/info", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", authHeader)
-
-	client := &http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		// Linkin server might be unreachable
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("linkin auth failed")
-	}
-
-	var linkinUser struct {
-		Uid      string `json:"uid"`
-		Username string `json:"username"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&linkinUser); err != nil {
-		return nil, err
-	}
-
-	if linkinUser.Username == "" {
-		return nil, errors.New("linkin user invalid")
-	}
-
-	// Find user in memos by username
-	// Note: We assume linkin usernames are unique and safe to map directly
-	user, err := a.store.GetUser(ctx, &store.FindUser{Username: &linkinUser.Username})
-	if err != nil {
-		return nil, err
+// patExpiry converts a PAT's protobuf expiry into a *time.Time for the
+// cache's TTL capping, or nil if the PAT never expires.
+func patExpiry(pat *storepb.PersonalAccessTokensUserSetting_PersonalAccessToken) *time.Time {
+	if pat.ExpiresAt == nil {
+		return nil
 	}
+	t := pat.ExpiresAt.AsTime()
+	return &t
+}
 
-	if user != nil {
-		return user, nil
+// AuthenticateByExternalConnector tries every configured external identity
+// provider Connector in order and resolves the first successful one to a
+// memos user, provisioning it if the connector's JIT policy allows. The
+// returned *time.Time is the upstream token's own expiry (nil if the
+// connector couldn't determine one), for callers capping a cached result.
+func (a *Authenticator) AuthenticateByExternalConnector(ctx context.Context, authHeader string) (*store.User, *time.Time, error) {
+	connectors := a.connectors.list()
+	if len(connectors) == 0 {
+		return nil, nil, errors.New("no external identity provider connectors configured")
 	}
 
-	// Create user if not exists (Shadow Account)
-	newUser := &store.User{
-		Username:     linkinUser.Username,
-		Nickname:     linkinUser.Username,
-		Role:         store.RoleUser,
-		Email:        "",
-		PasswordHash: "", // Empty password hash disables password login
-		RowStatus:    store.Normal,
-	}
-	createdUser, err := a.store.CreateUser(ctx, newUser)
-	if err != nil {
-		return nil, err
+	var lastErr error
+	for _, connector := range connectors {
+		identity, err := connector.Authenticate(ctx, authHeader)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		user, err := resolveOrProvisionUser(ctx, a.store, connector.Config(), identity)
+		return user, identity.ExpiresAt, err
 	}
-
-	return createdUser, nil
+	return nil, nil, errors.Wrap(lastErr, "no connector accepted the provided token")
 }
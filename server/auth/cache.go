@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/pkg/errors"
+
+	"github.com/usememos/memos/store"
+)
+
+const (
+	defaultAuthCacheSize         = 10_000
+	defaultExternalTokenCacheTTL = 60 * time.Second
+	defaultPATCacheTTL           = 5 * time.Minute
+)
+
+// authCacheEntry is what AuthCache stores per bearer token. Exactly one of
+// User/Claims is set, mirroring AuthResult.
+type authCacheEntry struct {
+	user      *store.User
+	claims    *UserClaims
+	expiresAt time.Time
+}
+
+// AuthCacheMetrics exposes cache effectiveness counters through the existing
+// telemetry surface.
+type AuthCacheMetrics struct {
+	Hits      atomic.Int64
+	Misses    atomic.Int64
+	Evictions atomic.Int64
+}
+
+// AuthCache is a bounded, TTL-expiring cache of resolved PAT and external
+// connector lookups, keyed by a salted hash of the raw bearer token so the
+// token itself is never held in memory. It exists to avoid a store round
+// trip (and, for external connectors, an HTTP call to the identity provider)
+// on every authenticated request.
+type AuthCache struct {
+	entries *lru.Cache[string, authCacheEntry]
+	salt    []byte
+	Metrics AuthCacheMetrics
+}
+
+// NewAuthCache builds an AuthCache with the given max size; salt should be
+// distinct per-process secret material (the Authenticator's JWT secret is
+// reused since it's already a private, per-deployment value). TTL is
+// enforced per-entry (see putUser), not by the underlying LRU, since
+// different token kinds get different default TTLs.
+func NewAuthCache(size int, salt []byte) *AuthCache {
+	if size <= 0 {
+		size = defaultAuthCacheSize
+	}
+	c := &AuthCache{salt: salt}
+	entries, err := lru.NewWithEvict[string, authCacheEntry](size, func(_ string, _ authCacheEntry) {
+		c.Metrics.Evictions.Add(1)
+	})
+	if err != nil {
+		// Only returns an error for a non-positive size, which can't happen
+		// given the guard above.
+		panic(errors.Wrap(err, "failed to construct auth cache"))
+	}
+	c.entries = entries
+	return c
+}
+
+func (c *AuthCache) key(token string) string {
+	mac := hmac.New(sha256.New, c.salt)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// getUser returns a cached *store.User for token, if present and not
+// expired. Staleness from user edits/archival is handled by explicit
+// invalidation (see subscribeToUserChanges) rather than a live version
+// check here, since re-checking the version would cost the same store round
+// trip the cache exists to avoid.
+func (c *AuthCache) getUser(token string) (*store.User, bool) {
+	key := c.key(token)
+	entry, ok := c.entries.Get(key)
+	if !ok || entry.user == nil {
+		c.Metrics.Misses.Add(1)
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		c.entries.Remove(key)
+		c.Metrics.Misses.Add(1)
+		return nil, false
+	}
+	c.Metrics.Hits.Add(1)
+	return entry.user, true
+}
+
+// putUser caches a resolved user for token, capped by both ttl and the
+// token's own expiry (whichever is sooner).
+func (c *AuthCache) putUser(token string, user *store.User, ttl time.Duration, tokenExpiresAt *time.Time) {
+	expiresAt := time.Now().Add(ttl)
+	if tokenExpiresAt != nil && tokenExpiresAt.Before(expiresAt) {
+		expiresAt = *tokenExpiresAt
+	}
+	c.entries.Add(c.key(token), authCacheEntry{user: user, expiresAt: expiresAt})
+}
+
+// invalidateUser drops every cache entry belonging to userID. Cache entries
+// are keyed by token hash, not user ID, so this walks the cache rather than
+// doing a point delete; the cache is small (<=10k entries by default) so
+// this is cheap relative to the DB/HTTP round trip it's saving.
+func (c *AuthCache) invalidateUser(userID int32) {
+	for _, key := range c.entries.Keys() {
+		entry, ok := c.entries.Peek(key)
+		if ok && entry.user != nil && entry.user.ID == userID {
+			c.entries.Remove(key)
+		}
+	}
+}
+
+// subscribeToUserChanges wires the cache up to the store's change
+// notifications so updates/archival and PAT/refresh-token deletions
+// invalidate promptly instead of waiting out the TTL.
+func (a *Authenticator) subscribeToUserChanges() {
+	a.store.OnUserChanged(func(userID int32) {
+		a.cache.invalidateUser(userID)
+	})
+	a.store.OnPersonalAccessTokenDeleted(func(userID int32, _ string) {
+		a.cache.invalidateUser(userID)
+	})
+	a.store.OnRefreshTokenFamilyRevoked(func(userID int32, _ string) {
+		a.cache.invalidateUser(userID)
+	})
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/usememos/memos/store"
+)
+
+// BenchmarkAuthCache_PATLookup demonstrates the DB round-trip reduction an
+// AuthCache hit saves relative to re-resolving a PAT from the store on every
+// request: without the cache every lookup costs one round trip, with it
+// only the first (cold) lookup does.
+func BenchmarkAuthCache_PATLookup(b *testing.B) {
+	var dbRoundTrips int64
+	resolveFromStore := func() *store.User {
+		atomic.AddInt64(&dbRoundTrips, 1)
+		return &store.User{ID: 1, Username: "bench"}
+	}
+
+	token := PersonalAccessTokenPrefix + "benchtoken"
+
+	b.Run("without_cache", func(b *testing.B) {
+		atomic.StoreInt64(&dbRoundTrips, 0)
+		for i := 0; i < b.N; i++ {
+			resolveFromStore()
+		}
+		b.ReportMetric(float64(atomic.LoadInt64(&dbRoundTrips))/float64(b.N), "db-roundtrips/op")
+	})
+
+	b.Run("with_cache", func(b *testing.B) {
+		atomic.StoreInt64(&dbRoundTrips, 0)
+		cache := NewAuthCache(defaultAuthCacheSize, []byte("bench-salt"))
+		for i := 0; i < b.N; i++ {
+			if _, ok := cache.getUser(token); !ok {
+				user := resolveFromStore()
+				cache.putUser(token, user, defaultPATCacheTTL, nil)
+			}
+		}
+		b.ReportMetric(float64(atomic.LoadInt64(&dbRoundTrips))/float64(b.N), "db-roundtrips/op")
+	})
+}
@@ -0,0 +1,286 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/usememos/memos/store"
+)
+
+// ConnectorType identifies the protocol a Connector speaks to its upstream
+// identity provider.
+type ConnectorType string
+
+const (
+	ConnectorTypeOIDC             ConnectorType = "oidc"
+	ConnectorTypeOAuth2           ConnectorType = "oauth2"
+	ConnectorTypeBearerIntrospect ConnectorType = "bearer_introspect"
+	// ConnectorTypeLinkinCompat keeps pre-existing Linkin deployments working
+	// without requiring an immediate migration to a real OIDC provider.
+	ConnectorTypeLinkinCompat ConnectorType = "linkin_compat"
+)
+
+// ClaimMapping describes how to pull memos user fields out of whatever claim
+// set a connector returns (ID token claims, userinfo response, or
+// introspection response).
+type ClaimMapping struct {
+	Username string `json:"username"` // defaults to "preferred_username" ("username" for bearer introspection)
+	Email    string `json:"email"`    // defaults to "email"
+	Nickname string `json:"nickname"` // defaults to "name"
+}
+
+// ConnectorConfig is the persisted, hot-reloadable configuration for a single
+// external identity provider. It is stored as a WorkspaceSetting value.
+type ConnectorConfig struct {
+	ID     string        `json:"id"`
+	Type   ConnectorType `json:"type"`
+	Issuer string        `json:"issuer,omitempty"`
+
+	ClientID     string   `json:"clientId"`
+	ClientSecret string   `json:"clientSecret"`
+	Scopes       []string `json:"scopes,omitempty"`
+
+	// UserInfoURL is required for plain OAuth2 connectors and optional for
+	// OIDC connectors (it is otherwise discovered).
+	UserInfoURL      string   `json:"userInfoUrl,omitempty"`
+	IntrospectionURL string   `json:"introspectionUrl,omitempty"`
+	AllowedAudiences []string `json:"allowedAudiences,omitempty"`
+
+	ClaimMapping ClaimMapping `json:"claimMapping"`
+
+	// JIT provisioning policy.
+	AllowJITProvisioning bool       `json:"allowJitProvisioning"`
+	AllowedEmailDomains  []string   `json:"allowedEmailDomains,omitempty"`
+	DefaultRole          store.Role `json:"defaultRole"`
+}
+
+// ExternalIdentity is the normalized result of a successful external
+// authentication, independent of which Connector produced it.
+type ExternalIdentity struct {
+	ConnectorID string
+	Subject     string
+	Username    string
+	Email       string
+	Nickname    string
+	// ExpiresAt is the upstream token's own expiry, when the connector can
+	// determine one (an OIDC ID token's exp claim, an introspection
+	// response's exp field). Callers must cap any caching of the resolved
+	// identity at this time; nil means the connector has no expiry to offer.
+	ExpiresAt *time.Time
+}
+
+// Connector authenticates a bearer credential against one external identity
+// provider and returns a normalized identity. Implementations must be safe
+// for concurrent use; Authenticator tries connectors sequentially on every
+// request, so Authenticate should be fast (cached JWKS, no per-call
+// discovery).
+type Connector interface {
+	ID() string
+	Config() ConnectorConfig
+	// Authenticate validates the raw Authorization header value (e.g.
+	// "Bearer <token>") and returns the resolved identity, or an error if the
+	// token does not belong to this connector.
+	Authenticate(ctx context.Context, authHeader string) (*ExternalIdentity, error)
+}
+
+// connectorRegistry holds the set of configured connectors and supports
+// hot-reload when workspace settings change.
+type connectorRegistry struct {
+	mu         sync.RWMutex
+	connectors []Connector
+}
+
+func newConnectorRegistry() *connectorRegistry {
+	return &connectorRegistry{}
+}
+
+func (r *connectorRegistry) set(connectors []Connector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connectors = connectors
+}
+
+func (r *connectorRegistry) list() []Connector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.connectors
+}
+
+// resolveOrProvisionUser finds the memos user matching an external identity,
+// provisioning a new shadow account only when the connector's JIT policy
+// allows it.
+//
+// The match is keyed on the stored (connector_id, subject) -> user link, not
+// on username: two different connectors can legitimately hand out identities
+// with the same username for different real people (most concretely, a
+// legacy linkin_compat connector running alongside a newly configured OIDC
+// connector), and matching on username alone would let an attacker on one
+// connector take over an account that actually belongs to someone else on
+// another. The one exception is linkin_compat itself, which predates this
+// link table and must keep resolving its existing users by username; that
+// first match gets a link persisted immediately so every later login for
+// that identity — on linkin_compat or anywhere else — goes through the link,
+// mirroring how dex binds a connector identity to a local user.
+func resolveOrProvisionUser(ctx context.Context, s *store.Store, cfg ConnectorConfig, identity *ExternalIdentity) (*store.User, error) {
+	if identity.Username == "" {
+		return nil, errors.New("external identity missing username")
+	}
+	if identity.Subject == "" {
+		return nil, errors.New("external identity missing subject")
+	}
+
+	if link, err := s.GetExternalIdentity(ctx, &store.FindExternalIdentity{
+		ConnectorID: &identity.ConnectorID,
+		Subject:     &identity.Subject,
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to look up external identity link")
+	} else if link != nil {
+		user, err := s.GetUser(ctx, &store.FindUser{ID: &link.UserID})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to look up linked user")
+		}
+		if user == nil {
+			return nil, errors.New("linked user no longer exists")
+		}
+		return user, nil
+	}
+
+	var user *store.User
+	if cfg.Type == ConnectorTypeLinkinCompat {
+		var err error
+		user, err = s.GetUser(ctx, &store.FindUser{Username: &identity.Username})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to look up user")
+		}
+	}
+
+	if user == nil {
+		if !cfg.AllowJITProvisioning {
+			return nil, errors.New("user does not exist and JIT provisioning is disabled")
+		}
+		if !emailAllowed(identity.Email, cfg.AllowedEmailDomains) {
+			return nil, errors.Errorf("email domain not allowed for JIT provisioning: %q", identity.Email)
+		}
+
+		nickname := identity.Nickname
+		if nickname == "" {
+			nickname = identity.Username
+		}
+		role := cfg.DefaultRole
+		if role == "" {
+			role = store.RoleUser
+		}
+		newUser := &store.User{
+			Username:     identity.Username,
+			Nickname:     nickname,
+			Email:        identity.Email,
+			Role:         role,
+			PasswordHash: "", // external identities never get a local password
+			RowStatus:    store.Normal,
+		}
+		created, err := s.CreateUser(ctx, newUser)
+		if err != nil {
+			return nil, err
+		}
+		user = created
+	}
+
+	if err := s.UpsertExternalIdentity(ctx, &store.ExternalIdentity{
+		ConnectorID: identity.ConnectorID,
+		Subject:     identity.Subject,
+		UserID:      user.ID,
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to persist external identity link")
+	}
+
+	return user, nil
+}
+
+func emailAllowed(email string, allowedDomains []string) bool {
+	if len(allowedDomains) == 0 {
+		return true
+	}
+	if email == "" {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range allowedDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func claimString(claims map[string]any, key, fallback string) string {
+	field := key
+	if field == "" {
+		field = fallback
+	}
+	if v, ok := claims[field].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func fetchJSON(ctx context.Context, client *http.Client, url, authHeader string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func defaultHTTPClient() *http.Client {
+	return &http.Client{Timeout: 5 * time.Second}
+}
+
+// httpFormBody encodes the given fields as an application/x-www-form-urlencoded body.
+func httpFormBody(fields map[string]string) io.Reader {
+	values := url.Values{}
+	for k, v := range fields {
+		values.Set(k, v)
+	}
+	return strings.NewReader(values.Encode())
+}
+
+// decodeIntrospectionResponse parses an RFC 7662 introspection response body
+// into both a structured `active` flag and the raw claim map, since the
+// claims returned alongside `active` vary by provider.
+func decodeIntrospectionResponse(resp *http.Response, out *struct {
+	Active bool `json:"active"`
+	Claims map[string]any
+}) error {
+	raw := map[string]any{}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return errors.Wrap(err, "failed to decode introspection response")
+	}
+	if active, ok := raw["active"].(bool); ok {
+		out.Active = active
+	}
+	out.Claims = raw
+	return nil
+}
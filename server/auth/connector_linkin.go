@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// linkinCompatConnector preserves the behavior of the original hard-coded
+// Linkin integration as an ordinary Connector, so existing deployments keep
+// working while they migrate to a real OIDC/OAuth2 provider config.
+//
+// Deprecated: new deployments should configure an OIDCConnector or
+// OAuth2Connector instead; this exists only for backward compatibility.
+type linkinCompatConnector struct {
+	cfg    ConnectorConfig
+	client *http.Client
+}
+
+const defaultLinkinUserInfoURL = "http://linkin.love/api/user/v1/info"
+
+// newLinkinCompatConnector builds the compatibility shim. If cfg.UserInfoURL
+// is unset, it falls back to the original hard-coded Linkin endpoint.
+func newLinkinCompatConnector(cfg ConnectorConfig) *linkinCompatConnector {
+	if cfg.UserInfoURL == "" {
+		cfg.UserInfoURL = defaultLinkinUserInfoURL
+	}
+	return &linkinCompatConnector{cfg: cfg, client: &http.Client{Timeout: 2 * time.Second}}
+}
+
+func (c *linkinCompatConnector) ID() string              { return c.cfg.ID }
+func (c *linkinCompatConnector) Config() ConnectorConfig { return c.cfg }
+
+func (c *linkinCompatConnector) Authenticate(ctx context.Context, authHeader string) (*ExternalIdentity, error) {
+	if authHeader == "" {
+		return nil, errors.New("empty auth header")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authHeader)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("linkin auth failed")
+	}
+
+	var linkinUser struct {
+		Uid      string `json:"uid"`
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&linkinUser); err != nil {
+		return nil, err
+	}
+	if linkinUser.Username == "" {
+		return nil, errors.New("linkin user invalid")
+	}
+
+	return &ExternalIdentity{
+		ConnectorID: c.cfg.ID,
+		Subject:     linkinUser.Uid,
+		Username:    linkinUser.Username,
+		Nickname:    linkinUser.Username,
+	}, nil
+}
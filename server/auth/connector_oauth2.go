@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/usememos/memos/store"
+)
+
+// OAuth2Connector authenticates bearer access tokens by forwarding them to a
+// configured userinfo URL, for providers that don't speak OIDC discovery.
+type OAuth2Connector struct {
+	cfg    ConnectorConfig
+	client *http.Client
+}
+
+// NewOAuth2Connector builds a Connector for a plain OAuth2 provider whose
+// userinfo endpoint is configured explicitly.
+func NewOAuth2Connector(cfg ConnectorConfig) *OAuth2Connector {
+	return &OAuth2Connector{cfg: cfg, client: defaultHTTPClient()}
+}
+
+func (c *OAuth2Connector) ID() string              { return c.cfg.ID }
+func (c *OAuth2Connector) Config() ConnectorConfig { return c.cfg }
+
+func (c *OAuth2Connector) Authenticate(ctx context.Context, authHeader string) (*ExternalIdentity, error) {
+	if c.cfg.UserInfoURL == "" {
+		return nil, errors.New("oauth2 connector missing userInfoUrl")
+	}
+	if ExtractBearerToken(authHeader) == "" {
+		return nil, errors.New("missing bearer token")
+	}
+
+	var info map[string]any
+	if err := fetchJSON(ctx, c.client, c.cfg.UserInfoURL, authHeader, &info); err != nil {
+		return nil, errors.Wrap(err, "userinfo fetch failed")
+	}
+
+	identity := &ExternalIdentity{
+		ConnectorID: c.cfg.ID,
+		Subject:     claimString(info, "sub", "sub"),
+		Username:    claimString(info, c.cfg.ClaimMapping.Username, "preferred_username"),
+		Email:       claimString(info, c.cfg.ClaimMapping.Email, "email"),
+		Nickname:    claimString(info, c.cfg.ClaimMapping.Nickname, "name"),
+	}
+	if identity.Username == "" {
+		return nil, errors.New("userinfo response missing mapped username claim")
+	}
+	return identity, nil
+}
+
+// BearerIntrospectionConnector authenticates opaque bearer tokens by calling
+// the provider's RFC 7662 introspection endpoint.
+type BearerIntrospectionConnector struct {
+	cfg    ConnectorConfig
+	client *http.Client
+}
+
+// NewBearerIntrospectionConnector builds a Connector that delegates token
+// validity checks to an upstream introspection endpoint.
+func NewBearerIntrospectionConnector(cfg ConnectorConfig) *BearerIntrospectionConnector {
+	return &BearerIntrospectionConnector{cfg: cfg, client: defaultHTTPClient()}
+}
+
+func (c *BearerIntrospectionConnector) ID() string              { return c.cfg.ID }
+func (c *BearerIntrospectionConnector) Config() ConnectorConfig { return c.cfg }
+
+func (c *BearerIntrospectionConnector) Authenticate(ctx context.Context, authHeader string) (*ExternalIdentity, error) {
+	token := ExtractBearerToken(authHeader)
+	if token == "" {
+		return nil, errors.New("missing bearer token")
+	}
+	if c.cfg.IntrospectionURL == "" {
+		return nil, errors.New("bearer introspection connector missing introspectionUrl")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.IntrospectionURL,
+		httpFormBody(map[string]string{"token": token}))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(c.cfg.ClientID, c.cfg.ClientSecret)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "introspection request failed")
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Active bool `json:"active"`
+		Claims map[string]any
+	}
+	if err := decodeIntrospectionResponse(resp, &result); err != nil {
+		return nil, err
+	}
+	if !result.Active {
+		return nil, errors.New("token reported inactive by introspection endpoint")
+	}
+
+	return &ExternalIdentity{
+		ConnectorID: c.cfg.ID,
+		Subject:     claimString(result.Claims, "sub", "sub"),
+		Username:    claimString(result.Claims, c.cfg.ClaimMapping.Username, "username"),
+		Email:       claimString(result.Claims, c.cfg.ClaimMapping.Email, "email"),
+		Nickname:    claimString(result.Claims, c.cfg.ClaimMapping.Nickname, "name"),
+		ExpiresAt:   claimUnixTime(result.Claims, "exp"),
+	}, nil
+}
+
+// claimUnixTime reads an RFC 7662 `exp`-style numeric Unix timestamp claim,
+// so the caller can cap caching of the resolved identity at the token's own
+// expiry instead of a flat TTL.
+func claimUnixTime(claims map[string]any, key string) *time.Time {
+	v, ok := claims[key]
+	if !ok {
+		return nil
+	}
+	seconds, ok := v.(float64)
+	if !ok {
+		return nil
+	}
+	t := time.Unix(int64(seconds), 0)
+	return &t
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"github.com/usememos/memos/store"
+)
+
+// oidcDiscoveryDocument is the subset of RFC 8414 /
+// /.well-known/openid-configuration fields a connector needs.
+type oidcDiscoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCConnector authenticates bearer ID tokens issued by an upstream OIDC
+// provider, verifying the signature against the provider's published JWKS.
+// The userinfo endpoint is fetched only to fill in a username the verified ID
+// token lacks; opaque (non-JWT) access tokens fail verification and are
+// rejected.
+type OIDCConnector struct {
+	cfg    ConnectorConfig
+	store  *store.Store
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscoveryDocument
+	jwks      jwt.Keyfunc
+	jwksAt    time.Time
+}
+
+const jwksCacheTTL = 10 * time.Minute
+
+// NewOIDCConnector builds a Connector that performs full OIDC discovery and
+// JWKS-based ID token verification.
+func NewOIDCConnector(cfg ConnectorConfig, s *store.Store) *OIDCConnector {
+	return &OIDCConnector{
+		cfg:    cfg,
+		store:  s,
+		client: defaultHTTPClient(),
+	}
+}
+
+func (c *OIDCConnector) ID() string              { return c.cfg.ID }
+func (c *OIDCConnector) Config() ConnectorConfig { return c.cfg }
+
+// Authenticate verifies the bearer token as a JWT signed by the provider's
+// JWKS, then checks issuer/audience/expiry before resolving the memos user.
+func (c *OIDCConnector) Authenticate(ctx context.Context, authHeader string) (*ExternalIdentity, error) {
+	token := ExtractBearerToken(authHeader)
+	if token == "" {
+		return nil, errors.New("missing bearer token")
+	}
+
+	keyFunc, err := c.keyFunc(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load provider JWKS")
+	}
+
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, keyFunc, jwt.WithIssuer(c.cfg.Issuer), jwt.WithValidMethods([]string{"RS256"}))
+	if err != nil || !parsed.Valid {
+		return nil, errors.Wrap(err, "id token verification failed")
+	}
+	if !c.audienceAllowed(claims) {
+		return nil, errors.New("id token audience not allowed")
+	}
+
+	identity := &ExternalIdentity{
+		ConnectorID: c.cfg.ID,
+		Subject:     claimString(claims, "sub", "sub"),
+		Username:    claimString(claims, c.cfg.ClaimMapping.Username, "preferred_username"),
+		Email:       claimString(claims, c.cfg.ClaimMapping.Email, "email"),
+		Nickname:    claimString(claims, c.cfg.ClaimMapping.Nickname, "name"),
+		ExpiresAt:   expirationTime(claims),
+	}
+
+	// Providers that omit preferred_username from the ID token still expose
+	// it via userinfo; fetch it lazily rather than failing closed.
+	if identity.Username == "" {
+		if err := c.enrichFromUserInfo(ctx, authHeader, identity); err != nil {
+			return nil, err
+		}
+	}
+
+	return identity, nil
+}
+
+// expirationTime extracts the ID token's exp claim, if present, so callers
+// can cap any caching of the resolved identity at the token's own expiry
+// instead of a flat TTL.
+func expirationTime(claims jwt.MapClaims) *time.Time {
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil
+	}
+	t := exp.Time
+	return &t
+}
+
+func (c *OIDCConnector) audienceAllowed(claims jwt.MapClaims) bool {
+	if len(c.cfg.AllowedAudiences) == 0 {
+		return true
+	}
+	aud, err := claims.GetAudience()
+	if err != nil {
+		return false
+	}
+	for _, got := range aud {
+		for _, want := range c.cfg.AllowedAudiences {
+			if got == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *OIDCConnector) enrichFromUserInfo(ctx context.Context, authHeader string, identity *ExternalIdentity) error {
+	doc, err := c.discoveryDocument(ctx)
+	if err != nil {
+		return err
+	}
+	endpoint := c.cfg.UserInfoURL
+	if endpoint == "" {
+		endpoint = doc.UserInfoEndpoint
+	}
+	if endpoint == "" {
+		return errors.New("no userinfo endpoint available")
+	}
+
+	var info map[string]any
+	if err := fetchJSON(ctx, c.client, endpoint, authHeader, &info); err != nil {
+		return errors.Wrap(err, "userinfo fetch failed")
+	}
+	if identity.Username == "" {
+		identity.Username = claimString(info, c.cfg.ClaimMapping.Username, "preferred_username")
+	}
+	if identity.Email == "" {
+		identity.Email = claimString(info, c.cfg.ClaimMapping.Email, "email")
+	}
+	if identity.Nickname == "" {
+		identity.Nickname = claimString(info, c.cfg.ClaimMapping.Nickname, "name")
+	}
+	return nil
+}
+
+func (c *OIDCConnector) discoveryDocument(ctx context.Context) (*oidcDiscoveryDocument, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.discovery != nil {
+		return c.discovery, nil
+	}
+
+	var doc oidcDiscoveryDocument
+	wellKnown := c.cfg.Issuer + "/.well-known/openid-configuration"
+	if err := fetchJSON(ctx, c.client, wellKnown, "", &doc); err != nil {
+		return nil, err
+	}
+	c.discovery = &doc
+	return c.discovery, nil
+}
+
+// keyFunc returns a jwt.Keyfunc backed by the provider's cached JWKS,
+// refreshing it once jwksCacheTTL has passed. A token whose kid is not in the
+// cached set is rejected; it does not trigger an early refresh.
+func (c *OIDCConnector) keyFunc(ctx context.Context) (jwt.Keyfunc, error) {
+	c.mu.Lock()
+	stale := c.jwks == nil || time.Since(c.jwksAt) > jwksCacheTTL
+	c.mu.Unlock()
+	if stale {
+		if err := c.refreshJWKS(ctx); err != nil {
+			return nil, err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.jwks, nil
+}
+
+func (c *OIDCConnector) refreshJWKS(ctx context.Context) error {
+	doc, err := c.discoveryDocument(ctx)
+	if err != nil {
+		return err
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := fetchJSON(ctx, c.client, doc.JWKSURI, "", &jwks); err != nil {
+		return errors.Wrap(err, "jwks fetch failed")
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		pub, err := jwkToRSAPublicKey(k.N, k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return errors.New("jwks response contained no usable RSA keys")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jwks = func(token *jwt.Token) (any, error) {
+		// Defense in depth against algorithm-confusion attacks, matching the
+		// explicit method allow-list in parseAccessTokenV2WithKeys: never
+		// hand back an RSA public key for a token that doesn't claim an RSA
+		// signing method, even though jwt.WithValidMethods already narrows
+		// it to RS256 upstream.
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, errors.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys[kid]
+		if !ok {
+			return nil, errors.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	}
+	c.jwksAt = time.Now()
+	return nil
+}
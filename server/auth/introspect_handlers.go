@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/usememos/memos/internal/util"
+	"github.com/usememos/memos/store"
+)
+
+// RegisterIntrospectionHandlers mounts the RFC 7662 introspection and
+// RFC 7009 revocation endpoints alongside the OIDC handlers.
+func (a *Authenticator) RegisterIntrospectionHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("POST /oauth/introspect", a.handleIntrospect)
+	mux.HandleFunc("POST /oauth/revoke", a.handleRevoke)
+}
+
+// introspectRateLimitPerMinute bounds how many introspect/revoke calls a
+// single caller may make; both endpoints exist to be hit frequently by
+// resource servers, but not hammered.
+const introspectRateLimitPerMinute = 60
+
+// maxRateLimiterEntries bounds clientRateLimiter, the same way
+// defaultAuthCacheSize bounds AuthCache: callers are expected to be a small,
+// bounded set of trusted clients, but an unbounded map would still grow for
+// the process lifetime if that assumption is ever wrong.
+const maxRateLimiterEntries = 10_000
+
+// clientRateLimiter tracks one token-bucket limiter per caller identity
+// (the caller's username, see callerRateLimitKey), created lazily, in a
+// bounded LRU so a caller
+// with many distinct identities can't grow this map forever.
+type clientRateLimiter struct {
+	limiters *lru.Cache[string, *rate.Limiter]
+}
+
+func newClientRateLimiter() *clientRateLimiter {
+	limiters, err := lru.New[string, *rate.Limiter](maxRateLimiterEntries)
+	if err != nil {
+		// Only returns an error for a non-positive size, which can't happen
+		// given the constant above.
+		panic(errors.Wrap(err, "failed to construct client rate limiter"))
+	}
+	return &clientRateLimiter{limiters: limiters}
+}
+
+func (c *clientRateLimiter) allow(clientKey string) bool {
+	limiter, ok := c.limiters.Get(clientKey)
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(time.Minute/introspectRateLimitPerMinute), introspectRateLimitPerMinute)
+		c.limiters.Add(clientKey, limiter)
+	}
+	return limiter.Allow()
+}
+
+type introspectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+}
+
+// handleIntrospect implements POST /oauth/introspect (RFC 7662). The caller
+// must authenticate as an admin or as the same user the inspected token
+// belongs to; on any failure to validate the inspected token it returns
+// {"active": false} rather than leaking why.
+func (a *Authenticator) handleIntrospect(w http.ResponseWriter, r *http.Request) {
+	caller := a.Authenticate(r.Context(), r.Header.Get("Authorization"))
+	if caller == nil {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	if !a.rateLimiter.allow(callerRateLimitKey(caller)) {
+		http.Error(w, `{"error":"rate_limited"}`, http.StatusTooManyRequests)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+	token := r.FormValue("token")
+	if token == "" {
+		writeJSON(w, http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+
+	callerUser, err := a.resolveAuthResultUser(r.Context(), caller)
+	if err != nil || callerUser == nil {
+		writeJSON(w, http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+	isCallerPrivileged := callerUser.Role == store.RoleAdmin || callerUser.Role == store.RoleHost
+
+	// /oauth/revoke supports both access credentials and refresh tokens, so
+	// introspection has to cover the same credential space or a perfectly
+	// valid refresh token always reports inactive.
+	if resp, ok := a.introspectRefreshToken(r.Context(), token, callerUser, isCallerPrivileged); ok {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	result := a.Authenticate(r.Context(), "Bearer "+token)
+	if result == nil {
+		writeJSON(w, http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+	target, err := a.resolveAuthResultUser(r.Context(), result)
+	if err != nil || target == nil {
+		writeJSON(w, http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+
+	if !isCallerPrivileged && callerUser.ID != target.ID {
+		writeJSON(w, http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+
+	resp := introspectionResponse{
+		Active: true,
+		// client_id/username stay human-readable; sub must be the stable,
+		// never-reassigned user ID per OIDC, not the editable username.
+		ClientID:  target.Username,
+		Username:  target.Username,
+		Sub:       util.ConvertInt32ToString(target.ID),
+		Iss:       a.issuer,
+		TokenType: "access_token",
+	}
+	if result.Claims != nil {
+		resp.Exp = result.Claims.ExpiresAt.Unix()
+		resp.Iat = result.Claims.IssuedAt.Unix()
+		resp.Aud = a.issuer
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// introspectRefreshToken reports on token as a refresh token if it parses
+// and exists in the store, mirroring revokeRefreshTokenIfOwned's ownership
+// check: the caller must own the token or be an admin/host. The bool return
+// indicates whether token was structurally a refresh token at all, so the
+// caller can fall back to the access-token/PAT/external-connector path
+// otherwise.
+func (a *Authenticator) introspectRefreshToken(ctx context.Context, token string, caller *store.User, isCallerPrivileged bool) (introspectionResponse, bool) {
+	claims, err := ParseRefreshToken(token, []byte(a.secret))
+	if err != nil {
+		return introspectionResponse{}, false
+	}
+
+	userID, err := util.ConvertStringToInt32(claims.Subject)
+	if err != nil {
+		return introspectionResponse{Active: false}, true
+	}
+	if !isCallerPrivileged && caller.ID != userID {
+		return introspectionResponse{Active: false}, true
+	}
+
+	stored, err := a.store.GetUserRefreshTokenByID(ctx, userID, claims.TokenID)
+	if err != nil || stored == nil || stored.RotatedAt != nil {
+		return introspectionResponse{Active: false}, true
+	}
+	if stored.ExpiresAt != nil && stored.ExpiresAt.AsTime().Before(time.Now()) {
+		return introspectionResponse{Active: false}, true
+	}
+
+	user, err := a.store.GetUser(ctx, &store.FindUser{ID: &userID})
+	if err != nil || user == nil || user.RowStatus == store.Archived {
+		return introspectionResponse{Active: false}, true
+	}
+
+	resp := introspectionResponse{
+		Active:    true,
+		ClientID:  user.Username,
+		Username:  user.Username,
+		Sub:       util.ConvertInt32ToString(user.ID),
+		Iss:       a.issuer,
+		TokenType: "refresh_token",
+	}
+	if stored.ExpiresAt != nil {
+		resp.Exp = stored.ExpiresAt.AsTime().Unix()
+	}
+	if stored.CreatedAt != nil {
+		resp.Iat = stored.CreatedAt.AsTime().Unix()
+	}
+	return resp, true
+}
+
+// handleRevoke implements POST /oauth/revoke (RFC 7009): it deletes the
+// matching refresh token row or PAT hash so the credential can no longer be
+// used. Revocation is idempotent — revoking an already-revoked or unknown
+// token still returns 200, per the RFC.
+func (a *Authenticator) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	caller := a.Authenticate(r.Context(), r.Header.Get("Authorization"))
+	if caller == nil {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	if !a.rateLimiter.allow(callerRateLimitKey(caller)) {
+		http.Error(w, `{"error":"rate_limited"}`, http.StatusTooManyRequests)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+	token := r.FormValue("token")
+	hint := r.FormValue("token_type_hint")
+	if token == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	callerUser, err := a.resolveAuthResultUser(r.Context(), caller)
+	if err != nil || callerUser == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if strings.HasPrefix(token, PersonalAccessTokenPrefix) && hint != "refresh_token" {
+		a.revokePATIfOwned(r.Context(), callerUser, token)
+	} else {
+		a.revokeRefreshTokenIfOwned(r.Context(), callerUser, token)
+	}
+
+	// Always 200: per RFC 7009, revocation must not leak whether the token
+	// existed or belonged to someone else.
+	w.WriteHeader(http.StatusOK)
+}
+
+// revokePATIfOwned deletes the PAT matching token, but only when it belongs
+// to caller (or caller is an admin), so one user can't revoke another's
+// credential by guessing its value.
+func (a *Authenticator) revokePATIfOwned(ctx context.Context, caller *store.User, token string) {
+	tokenHash := HashPersonalAccessToken(token)
+	result, err := a.store.GetUserByPATHash(ctx, tokenHash)
+	if err != nil || result == nil {
+		return
+	}
+	if result.User.ID != caller.ID && caller.Role != store.RoleAdmin && caller.Role != store.RoleHost {
+		return
+	}
+	if err := a.store.DeletePersonalAccessToken(ctx, result.User.ID, result.PAT.TokenId); err != nil {
+		slog.Warn("failed to revoke PAT", "error", err, "userID", result.User.ID)
+	}
+}
+
+// revokeRefreshTokenIfOwned deletes the refresh token row matching the
+// opaque token value, but only when it belongs to caller (or caller is an
+// admin).
+func (a *Authenticator) revokeRefreshTokenIfOwned(ctx context.Context, caller *store.User, token string) {
+	claims, err := ParseRefreshToken(token, []byte(a.secret))
+	if err != nil {
+		return
+	}
+	userID, err := util.ConvertStringToInt32(claims.Subject)
+	if err != nil {
+		return
+	}
+	if userID != caller.ID && caller.Role != store.RoleAdmin && caller.Role != store.RoleHost {
+		return
+	}
+	if err := a.store.DeleteUserRefreshToken(ctx, userID, claims.TokenID); err != nil {
+		slog.Warn("failed to revoke refresh token", "error", err, "userID", userID)
+	}
+}
+
+func callerRateLimitKey(result *AuthResult) string {
+	if result.Claims != nil {
+		return result.Claims.Username
+	}
+	if result.User != nil {
+		return result.User.Username
+	}
+	return "unknown"
+}
@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/usememos/memos/store"
+)
+
+// signingKeyRotationInterval controls how often a fresh RSA signing key is
+// minted; the previous key is kept around long enough to verify tokens
+// signed before the rotation.
+const signingKeyRotationInterval = 7 * 24 * time.Hour
+
+// signingKey is one RSA keypair in the rotation, identified by a JWK `kid`.
+type signingKey struct {
+	kid        string
+	privateKey *rsa.PrivateKey
+	createdAt  time.Time
+}
+
+// SigningKeySet manages the RSA keypairs used to sign and verify access
+// tokens asymmetrically, so third parties can validate memos-issued tokens
+// against /oauth/jwks without sharing a shared secret.
+type SigningKeySet struct {
+	mu      sync.RWMutex
+	current *signingKey
+	// previous is retained after rotation so tokens signed just before a
+	// rotation still verify until they expire.
+	previous *signingKey
+	// lastPersistedRaw is the exact WorkspaceSetting value this replica last
+	// loaded or successfully wrote, used as the expected value in a
+	// compare-and-swap persist so two replicas racing to rotate the same
+	// overdue key can't silently clobber each other.
+	lastPersistedRaw string
+}
+
+// NewSigningKeySet generates an initial RSA keypair.
+func NewSigningKeySet() (*SigningKeySet, error) {
+	key, err := newSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKeySet{current: key}, nil
+}
+
+// signingKeysSettingKey is the WorkspaceSetting holding the signing key set
+// as JSON, so every replica behind a load balancer signs and verifies access
+// tokens with the same RSA keypair(s) instead of generating its own.
+const signingKeysSettingKey = "jwt_signing_keys"
+
+// persistedSigningKey is the JSON-serializable form of a signingKey.
+type persistedSigningKey struct {
+	Kid           string    `json:"kid"`
+	PrivateKeyPEM string    `json:"privateKeyPem"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// persistedSigningKeySet is the JSON shape stored under
+// signingKeysSettingKey.
+type persistedSigningKeySet struct {
+	Current  persistedSigningKey  `json:"current"`
+	Previous *persistedSigningKey `json:"previous,omitempty"`
+}
+
+// loadOrCreateSigningKeySet loads the shared signing key set from
+// store.WorkspaceSetting, or generates and persists a new one if no
+// deployment has ever created one yet.
+func loadOrCreateSigningKeySet(ctx context.Context, s *store.Store) (*SigningKeySet, error) {
+	raw, err := s.GetWorkspaceSetting(ctx, &store.FindWorkspaceSetting{Name: signingKeysSettingKey})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load signing key setting")
+	}
+	if raw != nil && raw.Value != "" {
+		var persisted persistedSigningKeySet
+		if err := json.Unmarshal([]byte(raw.Value), &persisted); err != nil {
+			return nil, errors.Wrap(err, "failed to parse signing key setting")
+		}
+		keys, err := signingKeySetFromPersisted(persisted)
+		if err != nil {
+			return nil, err
+		}
+		keys.lastPersistedRaw = raw.Value
+		return keys, nil
+	}
+
+	keys, err := NewSigningKeySet()
+	if err != nil {
+		return nil, err
+	}
+	if err := persistSigningKeySet(ctx, s, keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// persistSigningKeySet writes a rotated signing key set back to
+// store.WorkspaceSetting as a compare-and-swap against keys.lastPersistedRaw
+// — the value this replica last loaded or wrote. If another replica already
+// rotated and persisted its own new key set in the meantime, the swap is
+// rejected rather than overwriting it: this replica's own rotation is
+// discarded and it adopts the winning replica's key set instead, so every
+// replica converges on one key set instead of silently losing whichever one
+// lost the race (a problem a read-then-write persist can't avoid, same as
+// refresh token rotation in RotateRefreshToken).
+func persistSigningKeySet(ctx context.Context, s *store.Store, keys *SigningKeySet) error {
+	keys.mu.RLock()
+	persisted := persistedSigningKeySet{Current: toPersistedSigningKey(keys.current)}
+	if keys.previous != nil {
+		prev := toPersistedSigningKey(keys.previous)
+		persisted.Previous = &prev
+	}
+	expected := keys.lastPersistedRaw
+	keys.mu.RUnlock()
+
+	raw, err := json.Marshal(persisted)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal signing key setting")
+	}
+
+	swapped, err := s.CompareAndSwapWorkspaceSetting(ctx, signingKeysSettingKey, expected, string(raw))
+	if err != nil {
+		return errors.Wrap(err, "failed to persist signing key setting")
+	}
+	if swapped {
+		keys.mu.Lock()
+		keys.lastPersistedRaw = string(raw)
+		keys.mu.Unlock()
+		return nil
+	}
+
+	// Lost the race: another replica persisted first. Adopt its key set
+	// instead of retrying with our own, since ours is now stale.
+	current, err := s.GetWorkspaceSetting(ctx, &store.FindWorkspaceSetting{Name: signingKeysSettingKey})
+	if err != nil {
+		return errors.Wrap(err, "failed to reload signing key setting after lost rotation race")
+	}
+	if current == nil || current.Value == "" {
+		return errors.New("signing key setting vanished after lost rotation race")
+	}
+	var winning persistedSigningKeySet
+	if err := json.Unmarshal([]byte(current.Value), &winning); err != nil {
+		return errors.Wrap(err, "failed to parse signing key setting after lost rotation race")
+	}
+	winningKeys, err := signingKeySetFromPersisted(winning)
+	if err != nil {
+		return errors.Wrap(err, "failed to rebuild signing key set after lost rotation race")
+	}
+
+	keys.mu.Lock()
+	keys.current = winningKeys.current
+	keys.previous = winningKeys.previous
+	keys.lastPersistedRaw = current.Value
+	keys.mu.Unlock()
+	return nil
+}
+
+func toPersistedSigningKey(k *signingKey) persistedSigningKey {
+	return persistedSigningKey{
+		Kid:           k.kid,
+		PrivateKeyPEM: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k.privateKey)})),
+		CreatedAt:     k.createdAt,
+	}
+}
+
+func fromPersistedSigningKey(p persistedSigningKey) (*signingKey, error) {
+	block, _ := pem.Decode([]byte(p.PrivateKeyPEM))
+	if block == nil {
+		return nil, errors.New("invalid PEM-encoded signing key")
+	}
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse signing key")
+	}
+	return &signingKey{kid: p.Kid, privateKey: privateKey, createdAt: p.CreatedAt}, nil
+}
+
+func signingKeySetFromPersisted(p persistedSigningKeySet) (*SigningKeySet, error) {
+	current, err := fromPersistedSigningKey(p.Current)
+	if err != nil {
+		return nil, err
+	}
+	keys := &SigningKeySet{current: current}
+	if p.Previous != nil {
+		previous, err := fromPersistedSigningKey(*p.Previous)
+		if err != nil {
+			return nil, err
+		}
+		keys.previous = previous
+	}
+	return keys, nil
+}
+
+func newSigningKey() (*signingKey, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate signing key")
+	}
+	return &signingKey{
+		kid:        uuid.NewString(),
+		privateKey: privateKey,
+		createdAt:  time.Now(),
+	}, nil
+}
+
+// RotateIfDue replaces the current signing key with a freshly generated one
+// once signingKeyRotationInterval has elapsed, demoting the old key to
+// "previous" so in-flight tokens keep verifying. Reports whether a rotation
+// happened, so callers sharing the key set across replicas know when they
+// need to persist the new state.
+func (s *SigningKeySet) RotateIfDue() (rotated bool, err error) {
+	s.mu.RLock()
+	due := time.Since(s.current.createdAt) > signingKeyRotationInterval
+	s.mu.RUnlock()
+	if !due {
+		return false, nil
+	}
+
+	next, err := newSigningKey()
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	s.previous = s.current
+	s.current = next
+	s.mu.Unlock()
+	return true, nil
+}
+
+// SigningKey returns the current key to sign new tokens with.
+func (s *SigningKeySet) SigningKey() (kid string, key *rsa.PrivateKey) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.kid, s.current.privateKey
+}
+
+// PublicKeyByKID returns the public key matching a `kid`, checking both the
+// current and previous signing keys.
+func (s *SigningKeySet) PublicKeyByKID(kid string) (*rsa.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current.kid == kid {
+		return &s.current.privateKey.PublicKey, true
+	}
+	if s.previous != nil && s.previous.kid == kid {
+		return &s.previous.privateKey.PublicKey, true
+	}
+	return nil, false
+}
+
+// jwkRSAPublicKey is the JSON representation of an RSA JWK, as returned from
+// /oauth/jwks.
+type jwkRSAPublicKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS returns the public half of every active signing key in JWK Set form.
+func (s *SigningKeySet) JWKS() []jwkRSAPublicKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []jwkRSAPublicKey{rsaPublicKeyToJWK(s.current)}
+	if s.previous != nil {
+		keys = append(keys, rsaPublicKeyToJWK(s.previous))
+	}
+	return keys
+}
+
+func rsaPublicKeyToJWK(k *signingKey) jwkRSAPublicKey {
+	pub := k.privateKey.PublicKey
+	return jwkRSAPublicKey{
+		Kty: "RSA",
+		Use: "sig",
+		Kid: k.kid,
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/usememos/memos/internal/util"
+	"github.com/usememos/memos/store"
+)
+
+// RegisterOIDCHandlers mounts the OIDC-compliant discovery, JWKS, and
+// userinfo endpoints on mux, alongside the existing API routes, so
+// third-party applications can federate against memos as a real OIDC
+// provider (modelled on dex's handler set).
+func (a *Authenticator) RegisterOIDCHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /.well-known/openid-configuration", a.handleDiscovery)
+	mux.HandleFunc("GET /oauth/jwks", a.handleJWKS)
+	mux.HandleFunc("GET /oauth/userinfo", a.handleUserInfo)
+}
+
+type discoveryResponse struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	UserInfoEndpoint                 string   `json:"userinfo_endpoint"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IntrospectionEndpoint            string   `json:"introspection_endpoint"`
+	RevocationEndpoint               string   `json:"revocation_endpoint"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	ScopesSupported                  []string `json:"scopes_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+func (a *Authenticator) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, discoveryResponse{
+		Issuer:                           a.issuer,
+		AuthorizationEndpoint:            a.issuer + "/oauth/authorize",
+		TokenEndpoint:                    a.issuer + "/oauth/token",
+		UserInfoEndpoint:                 a.issuer + "/oauth/userinfo",
+		JWKSURI:                          a.issuer + "/oauth/jwks",
+		IntrospectionEndpoint:            a.issuer + "/oauth/introspect",
+		RevocationEndpoint:               a.issuer + "/oauth/revoke",
+		ResponseTypesSupported:           []string{"code", "token"},
+		SubjectTypesSupported:            []string{"public"},
+		ScopesSupported:                  []string{"openid", "profile", "email"},
+		IDTokenSigningAlgValuesSupported: []string{"RS256"},
+	})
+}
+
+func (a *Authenticator) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"keys": a.signingKeys.JWKS()})
+}
+
+type userInfoResponse struct {
+	Sub               string `json:"sub"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Role              string `json:"role"`
+}
+
+func (a *Authenticator) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	result := a.Authenticate(r.Context(), r.Header.Get("Authorization"))
+	if result == nil {
+		http.Error(w, `{"error":"invalid_token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := a.resolveAuthResultUser(r.Context(), result)
+	if err != nil || user == nil {
+		http.Error(w, `{"error":"invalid_token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, userInfoResponse{
+		// sub must be a stable identifier that is never reassigned; usernames
+		// are editable, so the numeric user ID is used instead.
+		Sub:               util.ConvertInt32ToString(user.ID),
+		PreferredUsername: user.Username,
+		Email:             user.Email,
+		Name:              user.Nickname,
+		Role:              string(user.Role),
+	})
+}
+
+// resolveAuthResultUser fetches the full store.User behind an AuthResult,
+// whether it came from stateless claims (Access Token V2) or an already
+// resolved user (PAT / external connector).
+func (a *Authenticator) resolveAuthResultUser(ctx context.Context, result *AuthResult) (*store.User, error) {
+	if result.User != nil {
+		return result.User, nil
+	}
+	if result.Claims == nil {
+		return nil, errors.New("no resolvable identity on auth result")
+	}
+	return a.store.GetUser(ctx, &store.FindUser{ID: &result.Claims.UserID})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
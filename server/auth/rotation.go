@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/usememos/memos/store"
+)
+
+// RotateRefreshToken implements RFC 6749 §10.4-style refresh token rotation:
+// every successful refresh retires the presented token and mints a
+// successor sharing its family_id. If a token that was already rotated is
+// presented again, the entire family is revoked and the caller must force a
+// fresh login, since the old token's presence implies it was stolen.
+func (a *Authenticator) RotateRefreshToken(ctx context.Context, userID int32, oldTokenID string, meta SessionRequestMetadata) (newRefreshToken string, newAccessToken string, err error) {
+	old, err := a.store.GetUserRefreshTokenByID(ctx, userID, oldTokenID)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to get refresh token")
+	}
+	if old == nil {
+		return "", "", errors.New("refresh token revoked")
+	}
+	if old.ExpiresAt != nil && old.ExpiresAt.AsTime().Before(time.Now()) {
+		return "", "", errors.New("refresh token expired")
+	}
+
+	user, err := a.store.GetUser(ctx, &store.FindUser{ID: &userID})
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to get user")
+	}
+	if user == nil || user.RowStatus == store.Archived {
+		return "", "", errors.New("user not found or archived")
+	}
+
+	newTokenID := uuid.NewString()
+	newRefreshToken, err = GenerateRefreshToken(user, newTokenID, []byte(a.secret))
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to generate refresh token")
+	}
+	newAccessToken, err = a.GenerateAccessTokenV2(user)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to generate access token")
+	}
+
+	// Claim the old token atomically before storing its successor: the store
+	// only flips rotated_at when it is still NULL, so of two concurrent
+	// callers presenting the same token, exactly one gets affected == 1. A
+	// read-then-write check here (read old.RotatedAt, decide, write) would
+	// let both callers pass the read and fork the family; affected-row count
+	// from the conditional UPDATE is the only thing that can't race.
+	now := timestamppb.Now()
+	affected, err := a.store.MarkUserRefreshTokenRotated(ctx, userID, oldTokenID, newTokenID, now)
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to mark refresh token as rotated")
+	}
+	if affected == 0 {
+		// Either already rotated (reuse) or revoked/deleted out from under us
+		// since the read above; either way, the chain may be compromised.
+		if revokeErr := a.store.RevokeUserRefreshTokenFamily(ctx, userID, old.FamilyID); revokeErr != nil {
+			slog.Error("failed to revoke refresh token family after reuse detection", "error", revokeErr, "userID", userID, "familyID", old.FamilyID)
+		}
+		a.cache.invalidateUser(userID)
+		return "", "", errors.New("refresh token reuse detected, family revoked")
+	}
+
+	if err := a.store.CreateUserRefreshToken(ctx, &store.UserRefreshToken{
+		UserID:     userID,
+		TokenID:    newTokenID,
+		FamilyID:   old.FamilyID,
+		ExpiresAt:  refreshTokenExpiry(),
+		CreatedAt:  now,
+		UserAgent:  meta.UserAgent,
+		ClientIP:   meta.ClientIP,
+		CreatedIP:  meta.ClientIP,
+		LastSeenAt: now,
+		LastSeenIP: meta.ClientIP,
+	}); err != nil {
+		return "", "", errors.Wrap(err, "failed to store rotated refresh token")
+	}
+
+	a.checkSuspiciousLogin(ctx, userID, newTokenID, old.LastSeenIP, meta.ClientIP)
+
+	return newRefreshToken, newAccessToken, nil
+}
+
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+func refreshTokenExpiry() *timestamppb.Timestamp {
+	return timestamppb.New(time.Now().Add(refreshTokenTTL))
+}
+
+// StartRefreshTokenFamilySweeper launches a background goroutine that
+// periodically deletes expired refresh token families, so reuse-detection
+// bookkeeping (rotated, revoked families) doesn't accumulate forever. It
+// returns a stop function; callers should invoke it on shutdown.
+func (a *Authenticator) StartRefreshTokenFamilySweeper(ctx context.Context, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := a.store.DeleteExpiredUserRefreshTokenFamilies(ctx, time.Now()); err != nil {
+					slog.Warn("failed to sweep expired refresh token families", "error", err)
+				}
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/usememos/memos/store"
+	"github.com/usememos/memos/test"
+)
+
+// TestRotateRefreshToken_ConcurrentReuse exercises the acceptance criterion
+// from the refresh token rotation request directly: of several clients
+// (eight here) presenting the same (not-yet-rotated) refresh token at the
+// same time, exactly one rotation must succeed and every other one must
+// fail, rather than more than one succeeding and forking the family.
+func TestRotateRefreshToken_ConcurrentReuse(t *testing.T) {
+	ctx := context.Background()
+	s := test.NewTestingStore(ctx, t)
+	authenticator := NewAuthenticator(s, "test-secret")
+
+	user, err := s.CreateUser(ctx, &store.User{
+		Username: "rotation-race",
+		Nickname: "rotation-race",
+	})
+	require.NoError(t, err)
+
+	tokenID := uuid.NewString()
+	familyID := uuid.NewString()
+	now := timestamppb.Now()
+	require.NoError(t, s.CreateUserRefreshToken(ctx, &store.UserRefreshToken{
+		UserID:    user.ID,
+		TokenID:   tokenID,
+		FamilyID:  familyID,
+		ExpiresAt: refreshTokenExpiry(),
+		CreatedAt: now,
+	}))
+
+	const concurrentCallers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, concurrentCallers)
+	for i := 0; i < concurrentCallers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, err := authenticator.RotateRefreshToken(ctx, user.ID, tokenID, SessionRequestMetadata{})
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	successes := 0
+	for _, err := range errs {
+		if err == nil {
+			successes++
+		}
+	}
+	require.Equal(t, 1, successes, "exactly one concurrent rotation of the same token should succeed")
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/usememos/memos/store"
+)
+
+// SessionRequestMetadata captures the request-level details worth recording
+// against a refresh token's session row.
+type SessionRequestMetadata struct {
+	UserAgent string
+	ClientIP  string
+}
+
+// RefreshTokenCookieName is the cookie the refresh token is carried in,
+// shared between the login/rotation flow that sets it and anything (like
+// SessionService) that needs to know which session a request belongs to.
+const RefreshTokenCookieName = "refresh_token"
+
+// CurrentSessionTokenID extracts the session (refresh token) id from a raw
+// refresh token value, without checking it against the store. It exists so
+// callers like SessionService can tell which of a user's sessions a request
+// came in on (e.g. to mark it "current" or exclude it from a "log out
+// everywhere else" action) — it is not an authentication check, since the
+// caller is assumed to already be authenticated by some other credential.
+func (a *Authenticator) CurrentSessionTokenID(refreshToken string) (string, error) {
+	if refreshToken == "" {
+		return "", errors.New("empty refresh token")
+	}
+	claims, err := ParseRefreshToken(refreshToken, []byte(a.secret))
+	if err != nil {
+		return "", errors.Wrap(err, "invalid refresh token")
+	}
+	return claims.TokenID, nil
+}
+
+// lastSeenWriteCoalesceWindow bounds how often AuthenticateByRefreshToken
+// writes last_seen_at/last_seen_ip for a given session, mirroring the
+// UpdatePATLastUsed fire-and-forget pattern but coalesced so a chatty client
+// doesn't turn every request into a write.
+const lastSeenWriteCoalesceWindow = time.Minute
+
+// GeoLookupFunc resolves an IP to a coarse location, used only to decide
+// whether a login looks suspicious. A nil func (the default) disables the
+// suspicious-login hook entirely.
+type GeoLookupFunc func(ip string) (asn string, country string, err error)
+
+// SuspiciousLoginHook is invoked when a refresh token authentication's or
+// rotation's client IP resolves to a different ASN or country than the
+// session's last known IP.
+// It exists so a future notification subsystem can alert the user without
+// this package needing to know how notifications are delivered.
+type SuspiciousLoginHook func(ctx context.Context, userID int32, tokenID, previousIP, newIP string)
+
+// recordSessionActivity updates last_seen_at/last_seen_ip for a session,
+// coalesced to at most one write per lastSeenWriteCoalesceWindow, and checks
+// whether the new client IP looks suspicious relative to previousIP (the
+// session's last known IP before this request).
+func (a *Authenticator) recordSessionActivity(ctx context.Context, userID int32, tokenID, previousIP string, meta SessionRequestMetadata) {
+	if meta.ClientIP == "" && meta.UserAgent == "" {
+		return
+	}
+
+	key := sessionCoalesceKey{userID: userID, tokenID: tokenID}
+	if a.lastSeenWrites.due(key) {
+		go func() {
+			now := timestamppb.Now()
+			if err := a.store.UpdateUserRefreshTokenLastSeen(context.Background(), userID, tokenID, meta.ClientIP, now); err != nil {
+				slog.Warn("failed to update refresh token last seen", "error", err, "userID", userID)
+			}
+		}()
+	}
+
+	a.checkSuspiciousLogin(ctx, userID, tokenID, previousIP, meta.ClientIP)
+}
+
+// checkSuspiciousLogin compares previousIP (the session's last known IP)
+// against newIP via the configured GeoLookupFunc, firing the hook on an
+// ASN/country mismatch. Lookup failures and an unconfigured GeoLookupFunc
+// are treated as "nothing to report", not an error.
+func (a *Authenticator) checkSuspiciousLogin(ctx context.Context, userID int32, tokenID, previousIP, newIP string) {
+	if a.geoLookup == nil || a.suspiciousLoginHook == nil || previousIP == "" || newIP == "" || previousIP == newIP {
+		return
+	}
+
+	prevASN, prevCountry, err := a.geoLookup(previousIP)
+	if err != nil {
+		return
+	}
+	newASN, newCountry, err := a.geoLookup(newIP)
+	if err != nil {
+		return
+	}
+	if prevASN == newASN && prevCountry == newCountry {
+		return
+	}
+
+	a.suspiciousLoginHook(ctx, userID, tokenID, previousIP, newIP)
+}
+
+type sessionCoalesceKey struct {
+	userID  int32
+	tokenID string
+}
+
+// maxCoalescedSessions bounds lastSeenCoalescer the same way
+// defaultAuthCacheSize bounds AuthCache, so a process with many distinct
+// sessions doesn't grow this map forever. Evicting early just means the
+// evicted session's next request pays for a write it could have coalesced
+// away, not a correctness problem.
+const maxCoalescedSessions = 10_000
+
+// lastSeenCoalescer tracks the last write time per session so
+// recordSessionActivity can skip writes inside lastSeenWriteCoalesceWindow.
+type lastSeenCoalescer struct {
+	seen *lru.Cache[sessionCoalesceKey, time.Time]
+}
+
+func newLastSeenCoalescer() *lastSeenCoalescer {
+	seen, err := lru.New[sessionCoalesceKey, time.Time](maxCoalescedSessions)
+	if err != nil {
+		// Only returns an error for a non-positive size, which can't happen
+		// given the constant above.
+		panic(errors.Wrap(err, "failed to construct last-seen coalescer"))
+	}
+	return &lastSeenCoalescer{seen: seen}
+}
+
+func (l *lastSeenCoalescer) due(key sessionCoalesceKey) bool {
+	if last, ok := l.seen.Get(key); ok && time.Since(last) < lastSeenWriteCoalesceWindow {
+		return false
+	}
+	l.seen.Add(key, time.Now())
+	return true
+}
+
+// SessionInfo is the user-facing view of one active refresh token session.
+type SessionInfo struct {
+	ID         string
+	UserAgent  string
+	ClientIP   string
+	CreatedIP  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	LastSeenIP string
+	Current    bool
+}
+
+func sessionInfoFromToken(t *store.UserRefreshToken, currentTokenID string) *SessionInfo {
+	info := &SessionInfo{
+		ID:         t.TokenID,
+		UserAgent:  t.UserAgent,
+		ClientIP:   t.ClientIP,
+		CreatedIP:  t.CreatedIP,
+		LastSeenIP: t.LastSeenIP,
+		Current:    t.TokenID == currentTokenID,
+	}
+	if t.CreatedAt != nil {
+		info.CreatedAt = t.CreatedAt.AsTime()
+	}
+	if t.LastSeenAt != nil {
+		info.LastSeenAt = t.LastSeenAt.AsTime()
+	}
+	return info
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/usememos/memos/store"
+)
+
+// SessionService exposes the caller's active refresh-token sessions and lets
+// them revoke one or all-but-the-current one. It sits alongside
+// Authenticator rather than inside it since it's a user-facing API surface,
+// not part of the authentication hot path.
+type SessionService struct {
+	store *store.Store
+}
+
+// NewSessionService builds a SessionService backed by store.
+func NewSessionService(s *store.Store) *SessionService {
+	return &SessionService{store: s}
+}
+
+// ListSessions returns every active (non-revoked, non-expired) session for
+// userID, marking which one matches currentTokenID.
+func (s *SessionService) ListSessions(ctx context.Context, userID int32, currentTokenID string) ([]*SessionInfo, error) {
+	tokens, err := s.store.ListUserRefreshTokens(ctx, &store.FindUserRefreshToken{UserID: &userID})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list sessions")
+	}
+
+	sessions := make([]*SessionInfo, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, sessionInfoFromToken(t, currentTokenID))
+	}
+	return sessions, nil
+}
+
+// RevokeSession deletes one session by ID, scoped to userID so a caller can
+// only revoke their own sessions.
+func (s *SessionService) RevokeSession(ctx context.Context, userID int32, sessionID string) error {
+	if err := s.store.DeleteUserRefreshToken(ctx, userID, sessionID); err != nil {
+		return errors.Wrap(err, "failed to revoke session")
+	}
+	return nil
+}
+
+// RevokeAllOtherSessions deletes every session for userID except
+// currentTokenID, e.g. for a "log out everywhere else" action.
+func (s *SessionService) RevokeAllOtherSessions(ctx context.Context, userID int32, currentTokenID string) error {
+	if err := s.store.DeleteUserRefreshTokensExcept(ctx, userID, currentTokenID); err != nil {
+		return errors.Wrap(err, "failed to revoke other sessions")
+	}
+	return nil
+}
+
+// AdminListSessions returns every active session across all users. Callers
+// must check the caller's role is admin/host themselves — this method does
+// not re-check authorization, matching how other store-backed admin listers
+// in this codebase work.
+func (s *SessionService) AdminListSessions(ctx context.Context) ([]*SessionInfo, error) {
+	tokens, err := s.store.ListUserRefreshTokens(ctx, &store.FindUserRefreshToken{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list sessions")
+	}
+
+	sessions := make([]*SessionInfo, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, sessionInfoFromToken(t, ""))
+	}
+	return sessions, nil
+}
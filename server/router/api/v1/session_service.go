@@ -0,0 +1,157 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	v1pb "github.com/usememos/memos/proto/gen/api/v1"
+	"github.com/usememos/memos/proto/gen/api/v1/v1connect"
+	"github.com/usememos/memos/server/auth"
+	"github.com/usememos/memos/store"
+)
+
+// SessionServiceServer implements the generated SessionService Connect
+// handler, making auth.SessionService's business logic reachable over
+// gRPC/Connect instead of being an otherwise-unreachable internal type.
+type SessionServiceServer struct {
+	v1connect.UnimplementedSessionServiceHandler
+
+	store         *store.Store
+	authenticator *auth.Authenticator
+	sessions      *auth.SessionService
+}
+
+// NewSessionServiceServer builds a SessionServiceServer backed by the given
+// store and Authenticator.
+func NewSessionServiceServer(s *store.Store, authenticator *auth.Authenticator) *SessionServiceServer {
+	return &SessionServiceServer{
+		store:         s,
+		authenticator: authenticator,
+		sessions:      auth.NewSessionService(s),
+	}
+}
+
+// ListSessions returns every active session belonging to the caller.
+func (s *SessionServiceServer) ListSessions(ctx context.Context, req *connect.Request[v1pb.ListSessionsRequest]) (*connect.Response[v1pb.ListSessionsResponse], error) {
+	user, tokenID, err := s.authenticatedUser(ctx, req.Header())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, err)
+	}
+
+	sessions, err := s.sessions.ListSessions(ctx, user.ID, tokenID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&v1pb.ListSessionsResponse{Sessions: toProtoSessions(sessions)}), nil
+}
+
+// RevokeSession revokes one of the caller's own sessions by id.
+func (s *SessionServiceServer) RevokeSession(ctx context.Context, req *connect.Request[v1pb.RevokeSessionRequest]) (*connect.Response[v1pb.RevokeSessionResponse], error) {
+	user, _, err := s.authenticatedUser(ctx, req.Header())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, err)
+	}
+
+	if err := s.sessions.RevokeSession(ctx, user.ID, req.Msg.GetSessionId()); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&v1pb.RevokeSessionResponse{}), nil
+}
+
+// RevokeAllOtherSessions revokes every session belonging to the caller
+// except the one the request was authenticated with.
+func (s *SessionServiceServer) RevokeAllOtherSessions(ctx context.Context, req *connect.Request[v1pb.RevokeAllOtherSessionsRequest]) (*connect.Response[v1pb.RevokeAllOtherSessionsResponse], error) {
+	user, tokenID, err := s.authenticatedUser(ctx, req.Header())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, err)
+	}
+
+	if err := s.sessions.RevokeAllOtherSessions(ctx, user.ID, tokenID); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&v1pb.RevokeAllOtherSessionsResponse{}), nil
+}
+
+// AdminListSessions returns every active session across all users. Requires
+// the caller to be an admin or host.
+func (s *SessionServiceServer) AdminListSessions(ctx context.Context, req *connect.Request[v1pb.AdminListSessionsRequest]) (*connect.Response[v1pb.AdminListSessionsResponse], error) {
+	user, _, err := s.authenticatedUser(ctx, req.Header())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, err)
+	}
+	if user.Role != store.RoleAdmin && user.Role != store.RoleHost {
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("admin or host role required"))
+	}
+
+	sessions, err := s.sessions.AdminListSessions(ctx)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&v1pb.AdminListSessionsResponse{Sessions: toProtoSessions(sessions)}), nil
+}
+
+// authenticatedUser resolves the caller and, when available, the refresh
+// token id the request was authenticated with (used to mark the "current"
+// session and to exclude it from RevokeAllOtherSessions). The access
+// credential (Authorization header) and the session identity (refresh token
+// cookie) are resolved independently, since a request authenticated by a
+// PAT or external connector still carries a browser session cookie whose
+// "current" session we want to identify.
+func (s *SessionServiceServer) authenticatedUser(ctx context.Context, header http.Header) (*store.User, string, error) {
+	result := s.authenticator.Authenticate(ctx, header.Get("Authorization"))
+	if result == nil {
+		return nil, "", errors.New("missing or invalid credentials")
+	}
+
+	tokenID := s.currentSessionTokenID(header)
+
+	if result.User != nil {
+		return result.User, tokenID, nil
+	}
+	if result.Claims == nil {
+		return nil, "", errors.New("no resolvable identity on auth result")
+	}
+	user, err := s.store.GetUser(ctx, &store.FindUser{ID: &result.Claims.UserID})
+	if err != nil || user == nil {
+		return nil, "", errors.New("user not found")
+	}
+	return user, tokenID, nil
+}
+
+// currentSessionTokenID pulls the refresh token cookie off header and
+// resolves its session (token) id, or "" if there's no cookie, it doesn't
+// parse, or it's otherwise unresolvable — callers treat that the same as
+// "no current session to mark", not an error.
+func (s *SessionServiceServer) currentSessionTokenID(header http.Header) string {
+	req := &http.Request{Header: header}
+	cookie, err := req.Cookie(auth.RefreshTokenCookieName)
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+	tokenID, err := s.authenticator.CurrentSessionTokenID(cookie.Value)
+	if err != nil {
+		return ""
+	}
+	return tokenID
+}
+
+func toProtoSessions(sessions []*auth.SessionInfo) []*v1pb.Session {
+	out := make([]*v1pb.Session, 0, len(sessions))
+	for _, session := range sessions {
+		out = append(out, &v1pb.Session{
+			Id:         session.ID,
+			UserAgent:  session.UserAgent,
+			ClientIp:   session.ClientIP,
+			CreatedIp:  session.CreatedIP,
+			CreatedAt:  timestamppb.New(session.CreatedAt),
+			LastSeenAt: timestamppb.New(session.LastSeenAt),
+			LastSeenIp: session.LastSeenIP,
+			Current:    session.Current,
+		})
+	}
+	return out
+}